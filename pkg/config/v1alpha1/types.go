@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Kubemacpool Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ControllerManagerConfig is the Schema used to configure the kubemacpool
+// controller manager from a single ConfigMap-mounted file instead of a long
+// list of CLI flags, matching the config file pattern controller-runtime
+// introduced for its own manager.
+//
+// +kubebuilder:object:root=true
+type ControllerManagerConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// LeaderElection configures the leader election behavior of the
+	// controller manager.
+	// +optional
+	LeaderElection *LeaderElectionConfiguration `json:"leaderElection,omitempty"`
+
+	// Namespace restricts the manager's cache, and so its controllers, to
+	// the given namespace. Defaults to all namespaces.
+	// +optional
+	Namespace *string `json:"namespace,omitempty"`
+
+	// SyncPeriod determines the minimum frequency at which watched
+	// resources are reconciled.
+	// +optional
+	SyncPeriod *metav1.Duration `json:"syncPeriod,omitempty"`
+
+	// MetricsBindAddress is the TCP address the manager binds to for
+	// serving Prometheus metrics.
+	// +optional
+	MetricsBindAddress *string `json:"metricsBindAddress,omitempty"`
+
+	// Webhook holds the admission webhook server configuration.
+	// +optional
+	Webhook WebhookConfiguration `json:"webhook,omitempty"`
+
+	// MacPool holds kubemacpool-specific settings, such as the MAC range it
+	// allocates addresses from.
+	// +optional
+	MacPool MacPoolConfiguration `json:"macPool,omitempty"`
+}
+
+// LeaderElectionConfiguration configures the leader election lock used by
+// the controller manager.
+type LeaderElectionConfiguration struct {
+	// LeaderElect enables leader election for the controller manager.
+	// +optional
+	LeaderElect *bool `json:"leaderElect,omitempty"`
+
+	// ResourceName is the name of the resource (e.g. ConfigMap) used for
+	// the leader election lock.
+	// +optional
+	ResourceName string `json:"resourceName,omitempty"`
+
+	// ResourceNamespace is the namespace in which the leader election
+	// resource will be created.
+	// +optional
+	ResourceNamespace string `json:"resourceNamespace,omitempty"`
+}
+
+// WebhookConfiguration configures the admission webhook server.
+type WebhookConfiguration struct {
+	// Port is the port the webhook server listens on.
+	// +optional
+	Port *int `json:"port,omitempty"`
+
+	// CertDir is the directory containing the webhook server's TLS
+	// certificate and key.
+	// +optional
+	CertDir string `json:"certDir,omitempty"`
+}
+
+// MacPoolConfiguration holds kubemacpool-specific settings.
+type MacPoolConfiguration struct {
+	// RangeStart is the first MAC address, inclusive, kubemacpool may
+	// allocate.
+	// +optional
+	RangeStart string `json:"rangeStart,omitempty"`
+
+	// RangeEnd is the last MAC address, inclusive, kubemacpool may
+	// allocate.
+	// +optional
+	RangeEnd string `json:"rangeEnd,omitempty"`
+
+	// WaitTime is how long, in seconds, kubemacpool waits before releasing
+	// a MAC address reserved for a VM or pod that never started.
+	// +optional
+	WaitTime *int `json:"waitTime,omitempty"`
+
+	// OptInMode, when true, makes kubemacpool only manage MAC addresses for
+	// namespaces explicitly opted in via label, instead of every namespace.
+	// +optional
+	OptInMode *bool `json:"optInMode,omitempty"`
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *ControllerManagerConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2019 The Kubemacpool Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/recorder"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+
+	internalrecorder "github.com/K8sNetworkPlumbingWG/kubemacpool/pkg/manager/recorder"
+)
+
+// Manager runs Controllers and webhooks against a home Cluster, with access
+// to the additional ("peer") clusters attached via Options.AdditionalClusters.
+// A plain kubemacpool deployment only ever talks to the Cluster it runs in,
+// but a federated / stretched KubeVirt deployment needs to reserve a MAC
+// address in every cluster that could run the VM, not just the one the
+// webhook request came from.
+type Manager interface {
+	// Cluster gives access to the home cluster's config, cache, client,
+	// REST mapper and event recorder - the same accessors GetCluster
+	// returns for a peer cluster.
+	Cluster
+
+	// GetScheme returns the scheme used to resolve runtime.Objects to
+	// GroupVersionKinds / Resources.
+	GetScheme() *runtime.Scheme
+
+	// GetFieldIndexer returns the indexer used to index objects in the cache.
+	GetFieldIndexer() client.FieldIndexer
+
+	// GetAdmissionDecoder returns the decoder webhooks use to decode
+	// AdmissionRequests.
+	GetAdmissionDecoder() types.Decoder
+
+	// Add passes r to SetFields and registers it to be started when Start
+	// is called. If the Manager has already started, r is started
+	// immediately instead; once Start's context is cancelled, Add returns
+	// an error.
+	Add(r Runnable) error
+
+	// SetFields is the hook for injecting the Manager's dependencies into
+	// i. The default Manager returned by New injects nothing.
+	SetFields(i interface{}) error
+
+	// Start starts every registered Runnable and blocks until ctx is
+	// cancelled. On cancellation it stops starting new Runnables, waits up
+	// to Options.GracefulShutdownTimeout for in-flight ones - including
+	// webhook admission.Handler calls and reconciles - to finish, releases
+	// the leader-election lock, and closes the metrics listener. This is
+	// what keeps a SIGTERM during a VM-create webhook from leaking a
+	// reserved MAC address in the ConfigMap without the reservation ever
+	// being finalized.
+	Start(ctx context.Context) error
+
+	// GetCluster returns the Cluster registered under name via
+	// Options.AdditionalClusters. It returns an error if no such cluster
+	// was configured.
+	GetCluster(name string) (Cluster, error)
+}
+
+// Cluster bundles everything needed to talk to a single Kubernetes cluster:
+// its REST config, cache, client, REST mapper and event recorder provider.
+// It mirrors the split controller-runtime's pkg/cluster performs internally,
+// so that reconcilers can reach clusters beyond the one the Manager itself
+// runs against.
+type Cluster interface {
+	// GetConfig returns the cluster's REST config.
+	GetConfig() *rest.Config
+
+	// GetCache returns the cluster's cache, used for cached reads and to
+	// register informers.
+	GetCache() cache.Cache
+
+	// GetClient returns a client for reading and writing objects in this
+	// cluster.
+	GetClient() client.Client
+
+	// GetRESTMapper returns the cluster's REST mapper.
+	GetRESTMapper() meta.RESTMapper
+
+	// GetEventRecorderFor returns an event recorder for this cluster, scoped
+	// to the given name.
+	GetEventRecorderFor(name string) record.EventRecorder
+}
+
+// ClusterOptions are the arguments for attaching an additional cluster to a
+// Manager via Options.AdditionalClusters. Config is required; Namespaces
+// defaults to all namespaces; every other field left unset falls back to
+// the corresponding field on the Manager's own Options.
+type ClusterOptions struct {
+	// Config is the REST config used to talk to this cluster.
+	Config *rest.Config
+
+	// Scheme is the scheme used to resolve runtime.Objects to
+	// GroupVersionKinds / Resources for this cluster.
+	// Defaults to the Manager's Scheme.
+	Scheme *runtime.Scheme
+
+	// MapperProvider provides the rest mapper used to map go types to
+	// Kubernetes APIs for this cluster. Defaults to the Manager's
+	// MapperProvider.
+	MapperProvider func(c *rest.Config, httpClient *http.Client) (meta.RESTMapper, error)
+
+	// HTTPClient is the http client used to create this cluster's Cache and
+	// Client. Defaults to the Manager's HTTPClient.
+	HTTPClient *http.Client
+
+	// SyncPeriod determines the minimum frequency at which watched
+	// resources in this cluster are reconciled. Defaults to the Manager's
+	// SyncPeriod.
+	SyncPeriod *time.Duration
+
+	// Namespaces restricts this cluster's cache to the given namespaces.
+	// Defaults to all namespaces.
+	Namespaces []string
+
+	// NewCache is the function used to create this cluster's cache.
+	// Defaults to the Manager's NewCache.
+	NewCache NewCacheFunc
+
+	// NewClient is the function used to create this cluster's client.
+	// Defaults to the Manager's NewClient.
+	NewClient NewClientFunc
+}
+
+// cluster is the default implementation of Cluster.
+type cluster struct {
+	config           *rest.Config
+	cache            cache.Cache
+	client           client.Client
+	mapper           meta.RESTMapper
+	recorderProvider recorder.Provider
+}
+
+func (c *cluster) GetConfig() *rest.Config        { return c.config }
+func (c *cluster) GetCache() cache.Cache          { return c.cache }
+func (c *cluster) GetClient() client.Client       { return c.client }
+func (c *cluster) GetRESTMapper() meta.RESTMapper { return c.mapper }
+
+func (c *cluster) GetEventRecorderFor(name string) record.EventRecorder {
+	return c.recorderProvider.GetEventRecorderFor(name)
+}
+
+// newCluster sets up a Cluster for one entry of Options.AdditionalClusters,
+// applying defaults inherited from the Manager's own Options for any
+// ClusterOptions field left unset.
+func newCluster(name string, opts ClusterOptions, base Options) (Cluster, error) {
+	if opts.Config == nil {
+		return nil, fmt.Errorf("must specify Config for cluster %q", name)
+	}
+
+	scheme := opts.Scheme
+	if scheme == nil {
+		scheme = base.Scheme
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = base.HTTPClient
+	}
+
+	mapperProvider := opts.MapperProvider
+	if mapperProvider == nil {
+		mapperProvider = base.MapperProvider
+	}
+	mapper, err := mapperProvider(opts.Config, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API Group-Resources for cluster %q: %v", name, err)
+	}
+
+	syncPeriod := opts.SyncPeriod
+	if syncPeriod == nil {
+		syncPeriod = base.SyncPeriod
+	}
+
+	newCacheFunc := opts.NewCache
+	if newCacheFunc == nil {
+		newCacheFunc = base.NewCache
+	}
+	clusterCache, err := newCacheFunc(opts.Config, cache.Options{Scheme: scheme, Mapper: mapper, Resync: syncPeriod, Namespaces: opts.Namespaces, HTTPClient: httpClient})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache for cluster %q: %v", name, err)
+	}
+
+	newClientFunc := opts.NewClient
+	if newClientFunc == nil {
+		newClientFunc = base.NewClient
+	}
+	clusterClient, err := newClientFunc(clusterCache, opts.Config, client.Options{Scheme: scheme, Mapper: mapper, HTTPClient: httpClient})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for cluster %q: %v", name, err)
+	}
+
+	recorderProvider, err := internalrecorder.NewProvider(opts.Config, httpClient, scheme, log.WithName("events").WithValues("cluster", name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recorder provider for cluster %q: %v", name, err)
+	}
+
+	return &cluster{
+		config:           opts.Config,
+		cache:            clusterCache,
+		client:           clusterClient,
+		mapper:           mapper,
+		recorderProvider: recorderProvider,
+	}, nil
+}
+
+// multiClusterManager wraps a Manager to additionally start the caches of,
+// and serve lookups for, the peer clusters attached through
+// Options.AdditionalClusters.
+type multiClusterManager struct {
+	Manager
+	clusters map[string]Cluster
+}
+
+func (m *multiClusterManager) GetCluster(name string) (Cluster, error) {
+	c, ok := m.clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("cluster %q is not configured", name)
+	}
+	return c, nil
+}
+
+// Start starts the caches of all attached clusters, waits for them to sync,
+// and then delegates to the wrapped Manager's Start so that a VM reconciled
+// against the home cluster can also reserve its MAC address in every peer
+// cluster. Start blocks until ctx is cancelled and, like the wrapped
+// Manager, honours Options.GracefulShutdownTimeout while draining in-flight
+// work before returning.
+//
+// A peer cluster's cache failing - even after the initial sync, e.g. its
+// watch connection drops - cancels the wrapped Manager and is returned as
+// an error instead of only being logged: otherwise this replica would keep
+// serving MAC-allocation admission requests while silently blind to that
+// cluster, risking the same cross-cluster duplicate-allocation this
+// multi-cluster support exists to prevent.
+func (m *multiClusterManager) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	clusterErrs := make(chan error, len(m.clusters))
+	for name, c := range m.clusters {
+		go func(name string, c Cluster) {
+			if err := c.GetCache().Start(ctx); err != nil {
+				clusterErrs <- fmt.Errorf("cache for cluster %q: %v", name, err)
+			}
+		}(name, c)
+	}
+
+	for name, c := range m.clusters {
+		if !c.GetCache().WaitForCacheSync(ctx) {
+			return fmt.Errorf("failed waiting for cluster %q caches to sync", name)
+		}
+	}
+
+	managerErr := make(chan error, 1)
+	go func() {
+		managerErr <- m.Manager.Start(ctx)
+	}()
+
+	select {
+	case err := <-clusterErrs:
+		cancel()
+		<-managerErr
+		return err
+	case err := <-managerErr:
+		return err
+	}
+}
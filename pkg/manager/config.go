@@ -0,0 +1,100 @@
+/*
+Copyright 2019 The Kubemacpool Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"sigs.k8s.io/yaml"
+
+	configv1alpha1 "github.com/K8sNetworkPlumbingWG/kubemacpool/pkg/config/v1alpha1"
+)
+
+// LoadFromFile reads a versioned ControllerManagerConfig (see
+// pkg/config/v1alpha1) from path and returns a copy of o with any field the
+// caller left unset filled in from the file. Fields already set on o - e.g.
+// from CLI flags - always take precedence over the file, so a deployment
+// can ship a single ConfigMap-mounted file and still override individual
+// settings with flags. Unknown fields in the file are rejected.
+func (o Options) LoadFromFile(path string) (Options, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return o, fmt.Errorf("failed to read config file %q: %v", path, err)
+	}
+
+	cfg := &configv1alpha1.ControllerManagerConfig{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return o, fmt.Errorf("failed to parse config file %q: %v", path, err)
+	}
+
+	return o.andFrom(cfg), nil
+}
+
+// andFrom fills in any field of o left unset by the caller with the
+// corresponding value from cfg.
+func (o Options) andFrom(cfg *configv1alpha1.ControllerManagerConfig) Options {
+	if le := cfg.LeaderElection; le != nil {
+		if o.LeaderElection == nil && le.LeaderElect != nil {
+			o.LeaderElection = le.LeaderElect
+		}
+		if o.LeaderElectionID == "" {
+			o.LeaderElectionID = le.ResourceName
+		}
+		if o.LeaderElectionNamespace == "" {
+			o.LeaderElectionNamespace = le.ResourceNamespace
+		}
+	}
+
+	if len(o.Namespaces) == 0 && cfg.Namespace != nil {
+		o.Namespaces = []string{*cfg.Namespace}
+	}
+
+	if o.SyncPeriod == nil && cfg.SyncPeriod != nil {
+		o.SyncPeriod = &cfg.SyncPeriod.Duration
+	}
+
+	if o.MetricsBindAddress == "" && cfg.MetricsBindAddress != nil {
+		o.MetricsBindAddress = *cfg.MetricsBindAddress
+	}
+
+	if o.Port == nil && cfg.Webhook.Port != nil {
+		o.Port = cfg.Webhook.Port
+	}
+
+	if o.CertDir == "" && cfg.Webhook.CertDir != "" {
+		o.CertDir = cfg.Webhook.CertDir
+	}
+
+	if o.MacPoolRangeStart == "" && cfg.MacPool.RangeStart != "" {
+		o.MacPoolRangeStart = cfg.MacPool.RangeStart
+	}
+
+	if o.MacPoolRangeEnd == "" && cfg.MacPool.RangeEnd != "" {
+		o.MacPoolRangeEnd = cfg.MacPool.RangeEnd
+	}
+
+	if o.MacPoolWaitTime == nil && cfg.MacPool.WaitTime != nil {
+		o.MacPoolWaitTime = cfg.MacPool.WaitTime
+	}
+
+	if o.MacPoolOptInMode == nil && cfg.MacPool.OptInMode != nil {
+		o.MacPoolOptInMode = cfg.MacPool.OptInMode
+	}
+
+	return o
+}
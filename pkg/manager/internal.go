@@ -0,0 +1,304 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// controllerManager is this package's own Manager implementation: unlike
+// the rest of this package, it has no vendor/sigs.k8s.io/controller-runtime
+// counterpart to mirror, because starting and gating Runnables on leader
+// election is kubemacpool-specific - two replicas must never hand out
+// allocations from the same MAC pool concurrently.
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/recorder"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+// Default lease timings for the leader-election campaign started by
+// controllerManager.Start when Options.LeaderElection is enabled. These
+// match the values controller-runtime itself has always defaulted to.
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+// Runnable allows a component to be started along with the Manager. Start
+// blocks until ctx is cancelled or the component is done.
+type Runnable interface {
+	Start(ctx context.Context) error
+}
+
+// controllerManager is the default implementation of Manager.
+type controllerManager struct {
+	config           *rest.Config
+	scheme           *runtime.Scheme
+	admissionDecoder types.Decoder
+	errChan          chan error
+	cache            cache.Cache
+	fieldIndexes     client.FieldIndexer
+	client           client.Client
+	recorderProvider recorder.Provider
+	resourceLock     resourcelock.Interface
+	mapper           meta.RESTMapper
+	metricsListener  net.Listener
+
+	// gracefulShutdownTimeout bounds how long Start waits, once its context
+	// is cancelled, for in-flight Runnables - including webhook
+	// admission.Handler calls and reconciles - to finish before the
+	// leader-election lock is released and the metrics listener is closed.
+	// A zero value waits forever.
+	gracefulShutdownTimeout time.Duration
+
+	mu            sync.Mutex
+	started       bool
+	startCtx      context.Context
+	runnables     []Runnable
+	runningWG     sync.WaitGroup
+	leaderElected bool
+
+	// internalStop/internalStopper let components that only understand the
+	// legacy <-chan struct{} Runnable signature observe Start's context
+	// being cancelled; internalStopper is closed once shutdown begins.
+	internalStop    <-chan struct{}
+	internalStopper chan<- struct{}
+}
+
+func (cm *controllerManager) GetConfig() *rest.Config              { return cm.config }
+func (cm *controllerManager) GetScheme() *runtime.Scheme           { return cm.scheme }
+func (cm *controllerManager) GetClient() client.Client             { return cm.client }
+func (cm *controllerManager) GetFieldIndexer() client.FieldIndexer { return cm.fieldIndexes }
+func (cm *controllerManager) GetCache() cache.Cache                { return cm.cache }
+func (cm *controllerManager) GetRESTMapper() meta.RESTMapper       { return cm.mapper }
+func (cm *controllerManager) GetAdmissionDecoder() types.Decoder   { return cm.admissionDecoder }
+
+func (cm *controllerManager) GetEventRecorderFor(name string) record.EventRecorder {
+	return cm.recorderProvider.GetEventRecorderFor(name)
+}
+
+// GetCluster always returns an error: controllerManager itself has no peer
+// clusters attached. New wraps it in a multiClusterManager, which overrides
+// GetCluster to serve Options.AdditionalClusters instead.
+func (cm *controllerManager) GetCluster(name string) (Cluster, error) {
+	return nil, fmt.Errorf("cluster %q is not configured", name)
+}
+
+// Add passes r to SetFields and registers it to be started when Start is
+// called. If the Manager has already started, r is started immediately
+// instead; once Start's context is cancelled, Add returns an error.
+func (cm *controllerManager) Add(r Runnable) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if err := cm.SetFields(r); err != nil {
+		return err
+	}
+
+	if cm.started {
+		if cm.startCtx.Err() != nil {
+			return fmt.Errorf("manager is shutting down")
+		}
+		cm.startRunnable(cm.startCtx, r)
+		return nil
+	}
+
+	cm.runnables = append(cm.runnables, r)
+	return nil
+}
+
+// SetFields is a placeholder for dependency injection into Runnables that
+// implement one of the well-known inject.* interfaces; kubemacpool does not
+// register any such Runnable today.
+func (cm *controllerManager) SetFields(i interface{}) error {
+	return nil
+}
+
+// Start starts the cache, then - if resourceLock is set - runs a
+// leader-election campaign and only starts the registered Runnables once
+// this replica is elected leader; otherwise it starts them right away.
+// Start blocks until ctx is cancelled or a Runnable errors, then drains
+// in-flight Runnables - bounded by gracefulShutdownTimeout - releases the
+// leader-election lock if this replica held it, and closes the metrics
+// listener, so a SIGTERM arriving mid VM-create webhook gives the in-flight
+// admission.Handler call a chance to finish reserving its MAC address
+// before the process exits.
+func (cm *controllerManager) Start(ctx context.Context) error {
+	cm.mu.Lock()
+	if cm.started {
+		cm.mu.Unlock()
+		return fmt.Errorf("manager already started")
+	}
+	cm.started = true
+	cm.startCtx = ctx
+	runnables := cm.runnables
+	cm.mu.Unlock()
+
+	go func() {
+		if err := cm.cache.Start(ctx); err != nil {
+			cm.reportError(err)
+		}
+	}()
+	if !cm.cache.WaitForCacheSync(ctx) {
+		return fmt.Errorf("failed waiting for cache to sync")
+	}
+
+	if cm.resourceLock != nil {
+		if err := cm.startLeaderElection(ctx, runnables); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range runnables {
+			cm.startRunnable(ctx, r)
+		}
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-cm.errChan:
+	}
+
+	if err := cm.shutdown(); err != nil {
+		if runErr == nil {
+			runErr = err
+		}
+	}
+
+	return runErr
+}
+
+// startLeaderElection runs a leader-election campaign for resourceLock and
+// only starts runnables once this replica is elected leader - so two
+// replicas can never both serve MAC-allocation reconciles and webhooks
+// against the same pool at the same time. It returns once the campaign has
+// been launched; the campaign itself keeps running, via ctx, in the
+// background until ctx is cancelled or leadership is lost.
+func (cm *controllerManager) startLeaderElection(ctx context.Context, runnables []Runnable) error {
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          cm.resourceLock,
+		LeaseDuration: defaultLeaseDuration,
+		RenewDeadline: defaultRenewDeadline,
+		RetryPeriod:   defaultRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				cm.mu.Lock()
+				cm.leaderElected = true
+				cm.mu.Unlock()
+
+				for _, r := range runnables {
+					cm.startRunnable(ctx, r)
+				}
+			},
+			OnStoppedLeading: func() {
+				cm.reportError(fmt.Errorf("leader election lost"))
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create leader elector: %v", err)
+	}
+
+	go elector.Run(ctx)
+	return nil
+}
+
+// startRunnable starts r in its own goroutine, tracked by runningWG so
+// shutdown can wait for it to finish within gracefulShutdownTimeout.
+func (cm *controllerManager) startRunnable(ctx context.Context, r Runnable) {
+	cm.runningWG.Add(1)
+	go func() {
+		defer cm.runningWG.Done()
+		if err := r.Start(ctx); err != nil {
+			cm.reportError(err)
+		}
+	}()
+}
+
+func (cm *controllerManager) reportError(err error) {
+	select {
+	case cm.errChan <- err:
+	default:
+		log.Error(err, "runnable failed after Start had already begun returning")
+	}
+}
+
+// shutdown waits up to gracefulShutdownTimeout for every started Runnable to
+// return, then releases the leader-election lock and closes the metrics
+// listener.
+func (cm *controllerManager) shutdown() error {
+	close(cm.internalStopper)
+
+	drained := make(chan struct{})
+	go func() {
+		cm.runningWG.Wait()
+		close(drained)
+	}()
+
+	if cm.gracefulShutdownTimeout > 0 {
+		select {
+		case <-drained:
+		case <-time.After(cm.gracefulShutdownTimeout):
+			log.Info("graceful shutdown timeout reached before all runnables finished")
+		}
+	} else {
+		<-drained
+	}
+
+	cm.mu.Lock()
+	leaderElected := cm.leaderElected
+	cm.mu.Unlock()
+
+	// Only release the lock if this replica actually won the campaign -
+	// releasing it otherwise would clear the holder identity out from under
+	// whichever replica is genuinely leading.
+	if cm.resourceLock != nil && leaderElected {
+		if err := cm.releaseLeaderElectionLock(); err != nil {
+			log.Error(err, "failed to release leader election lock")
+		}
+	}
+
+	if err := cm.metricsListener.Close(); err != nil {
+		return fmt.Errorf("failed to close metrics listener: %v", err)
+	}
+
+	return nil
+}
+
+// releaseLeaderElectionLock clears the lock record's holder identity so the
+// next manager instance can acquire leadership immediately instead of
+// waiting out the full lease duration.
+func (cm *controllerManager) releaseLeaderElectionLock() error {
+	record, _, err := cm.resourceLock.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	record.HolderIdentity = ""
+	return cm.resourceLock.Update(context.Background(), *record)
+}
@@ -23,11 +23,13 @@ package manager
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"time"
 
 	"github.com/go-logr/logr"
 
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -36,7 +38,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/leaderelection"
-	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/recorder"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -45,6 +46,9 @@ import (
 	internalrecorder "github.com/K8sNetworkPlumbingWG/kubemacpool/pkg/manager/recorder"
 )
 
+// defaultGracefulShutdownPeriod is the default Options.GracefulShutdownTimeout.
+const defaultGracefulShutdownPeriod = 30 * time.Second
+
 // Options are the arguments for creating a new Manager
 type Options struct {
 	// Scheme is the scheme used to resolve runtime.Objects to GroupVersionKinds / Resources
@@ -52,7 +56,17 @@ type Options struct {
 	Scheme *runtime.Scheme
 
 	// MapperProvider provides the rest mapper used to map go types to Kubernetes APIs
-	MapperProvider func(c *rest.Config) (meta.RESTMapper, error)
+	MapperProvider func(c *rest.Config, httpClient *http.Client) (meta.RESTMapper, error)
+
+	// HTTPClient is the http client that will be used to create the default
+	// Cache and Client, and shared by the discovery MapperProvider, the
+	// recorder provider and the leader-election resource lock. Defaults to
+	// rest.HTTPClientFor(Config) if unset. Set this to let a single
+	// transport - with custom TLS roots, proxy settings, impersonation
+	// headers or OpenTelemetry round-tripper instrumentation - back every
+	// subsystem that talks to the API server instead of each one dialing
+	// its own connection.
+	HTTPClient *http.Client
 
 	// SyncPeriod determines the minimum frequency at which watched resources are
 	// reconciled. A lower period will correct entropy more quickly, but reduce
@@ -61,8 +75,13 @@ type Options struct {
 	SyncPeriod *time.Duration
 
 	// LeaderElection determines whether or not to use leader election when
-	// starting the manager.
-	LeaderElection bool
+	// starting the manager. A pointer so that an explicit "false" from a CLI
+	// flag can be told apart from "left unset" when merged with a config
+	// file via andFrom - a plain bool can't represent that distinction, and
+	// the zero value would otherwise let the file silently re-enable leader
+	// election after a flag explicitly turned it off.
+	// Defaults to false if unset.
+	LeaderElection *bool
 
 	// LeaderElectionNamespace determines the namespace in which the leader
 	// election configmap will be created.
@@ -72,16 +91,82 @@ type Options struct {
 	// will use for holding the leader lock.
 	LeaderElectionID string
 
-	// Namespace if specified restricts the manager's cache to watch objects in the desired namespace
+	// Namespaces, if specified, restricts the manager's cache to watch objects in the given namespaces
 	// Defaults to all namespaces
-	// Note: If a namespace is specified then controllers can still Watch for a cluster-scoped resource e.g Node
-	// For namespaced resources the cache will only hold objects from the desired namespace.
-	Namespace string
+	// Note: Controllers can still Watch for a cluster-scoped resource e.g Node regardless of Namespaces.
+	// For namespaced resources the cache will only hold objects from the given namespaces.
+	Namespaces []string
+
+	// DefaultLabelSelector restricts the manager's cache to only the objects matching the
+	// selector, for every GVK without a more specific entry in SelectorsByObject. Kubemacpool
+	// watches every Pod and VM in the cluster by default; on large clusters this dominates
+	// memory, so this - together with SelectorsByObject and Namespaces - lets operators narrow
+	// the cache to just the objects actually reconciled, e.g. those labeled
+	// kubemacpool.io/opt-in=true.
+	DefaultLabelSelector labels.Selector
+
+	// SelectorsByObject overrides DefaultLabelSelector and Namespaces on a per client.Object
+	// basis, for callers that need a label or field selector that differs between GVKs.
+	SelectorsByObject map[client.Object]cache.ObjectSelector
 
 	// MetricsBindAddress is the TCP address that the controller should bind to
 	// for serving prometheus metrics
 	MetricsBindAddress string
 
+	// Port is the port the webhook server binds to. A pointer for the same
+	// reason as LeaderElection: 0 is a value an operator could legitimately
+	// pass on the CLI, so it can't double as the "unset, fall back to the
+	// config file" sentinel in andFrom.
+	Port *int
+
+	// CertDir is the directory containing the webhook server's TLS
+	// certificate and key.
+	CertDir string
+
+	// MacPoolRangeStart is the first MAC address, inclusive, kubemacpool may
+	// allocate.
+	MacPoolRangeStart string
+
+	// MacPoolRangeEnd is the last MAC address, inclusive, kubemacpool may
+	// allocate.
+	MacPoolRangeEnd string
+
+	// MacPoolWaitTime is how long, in seconds, kubemacpool waits before
+	// releasing a MAC address reserved for a VM or pod that never started.
+	// A pointer so an explicit 0 (no wait) survives being merged with a
+	// config file via andFrom instead of being mistaken for "unset".
+	MacPoolWaitTime *int
+
+	// MacPoolOptInMode, when true, makes kubemacpool only manage MAC
+	// addresses for namespaces explicitly opted in via label, instead of
+	// every namespace. A pointer for the same reason as LeaderElection: an
+	// explicit "false" must not be overridable by a config file's "true".
+	MacPoolOptInMode *bool
+
+	// GracefulShutdownTimeout is the duration given to Start, once its
+	// context is cancelled, to let in-flight admission.Handler calls and
+	// reconciles finish before the leader-election lock is released and
+	// the metrics listener is closed. A SIGTERM arriving mid VM-create
+	// webhook would otherwise be able to leak a reserved MAC address in
+	// the ConfigMap without the reservation ever being finalized.
+	// Defaults to defaultGracefulShutdownPeriod. A zero value waits
+	// forever for in-flight work to finish.
+	GracefulShutdownTimeout *time.Duration
+
+	// ConfigFile, if set, is the path to a versioned ControllerManagerConfig
+	// YAML file (see pkg/config/v1alpha1) that is loaded via LoadFromFile and
+	// merged into Options before defaults are applied. Any field already set
+	// above takes precedence over the value in the file.
+	ConfigFile string
+
+	// AdditionalClusters allows attaching one or more peer clusters to this
+	// Manager, keyed by a caller-chosen name, in addition to the "home"
+	// cluster the Manager itself runs against. This lets reconcilers
+	// coordinate state - such as MAC address allocation - across multiple
+	// Kubernetes clusters, e.g. in a federated / stretched KubeVirt
+	// deployment. Peer clusters are reachable via Manager.GetCluster.
+	AdditionalClusters map[string]ClusterOptions
+
 	// Functions to all for a user to customize the values that will be injected.
 
 	// NewCache is the function that will create the cache to be used
@@ -94,8 +179,8 @@ type Options struct {
 	NewClient NewClientFunc
 
 	// Dependency injection for testing
-	newRecorderProvider func(config *rest.Config, scheme *runtime.Scheme, logger logr.Logger) (recorder.Provider, error)
-	newResourceLock     func(config *rest.Config, recorderProvider recorder.Provider, options leaderelection.Options) (resourcelock.Interface, error)
+	newRecorderProvider func(config *rest.Config, httpClient *http.Client, scheme *runtime.Scheme, logger logr.Logger) (recorder.Provider, error)
+	newResourceLock     func(config *rest.Config, httpClient *http.Client, recorderProvider recorder.Provider, options leaderelection.Options) (resourcelock.Interface, error)
 	newAdmissionDecoder func(scheme *runtime.Scheme) (types.Decoder, error)
 	newMetricsListener  func(addr string) (net.Listener, error)
 }
@@ -107,43 +192,69 @@ type NewCacheFunc func(config *rest.Config, opts cache.Options) (cache.Cache, er
 type NewClientFunc func(cache cache.Cache, config *rest.Config, options client.Options) (client.Client, error)
 
 // New returns a new Manager for creating Controllers.
-func New(config *rest.Config, options Options) (manager.Manager, error) {
+func New(config *rest.Config, options Options) (Manager, error) {
 	// Initialize a rest.config if none was specified
 	if config == nil {
 		return nil, fmt.Errorf("must specify Config")
 	}
 
+	if options.ConfigFile != "" {
+		var err error
+		options, err = options.LoadFromFile(options.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Default the HTTP client before the rest of the options so every
+	// subsystem below shares the same transport.
+	if options.HTTPClient == nil {
+		var err error
+		options.HTTPClient, err = rest.HTTPClientFor(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP client: %v", err)
+		}
+	}
+
 	// Set default values for options fields
 	options = setOptionsDefaults(options)
 
 	// Create the mapper provider
-	mapper, err := options.MapperProvider(config)
+	mapper, err := options.MapperProvider(config, options.HTTPClient)
 	if err != nil {
 		log.Error(err, "Failed to get API Group-Resources")
 		return nil, err
 	}
 
 	// Create the cache for the cached read client and registering informers
-	cache, err := options.NewCache(config, cache.Options{Scheme: options.Scheme, Mapper: mapper, Resync: options.SyncPeriod, Namespace: options.Namespace})
+	cache, err := options.NewCache(config, cache.Options{
+		Scheme:            options.Scheme,
+		Mapper:            mapper,
+		Resync:            options.SyncPeriod,
+		Namespaces:        options.Namespaces,
+		DefaultSelector:   cache.ObjectSelector{Label: options.DefaultLabelSelector},
+		SelectorsByObject: options.SelectorsByObject,
+		HTTPClient:        options.HTTPClient,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	writeObj, err := options.NewClient(cache, config, client.Options{Scheme: options.Scheme, Mapper: mapper})
+	writeObj, err := options.NewClient(cache, config, client.Options{Scheme: options.Scheme, Mapper: mapper, HTTPClient: options.HTTPClient})
 	if err != nil {
 		return nil, err
 	}
 	// Create the recorder provider to inject event recorders for the components.
 	// TODO(directxman12): the log for the event provider should have a context (name, tags, etc) specific
 	// to the particular controller that it's being injected into, rather than a generic one like is here.
-	recorderProvider, err := options.newRecorderProvider(config, options.Scheme, log.WithName("events"))
+	recorderProvider, err := options.newRecorderProvider(config, options.HTTPClient, options.Scheme, log.WithName("events"))
 	if err != nil {
 		return nil, err
 	}
 
 	// Create the resource lock to enable leader election)
-	resourceLock, err := options.newResourceLock(config, recorderProvider, leaderelection.Options{
-		LeaderElection:          options.LeaderElection,
+	resourceLock, err := options.newResourceLock(config, options.HTTPClient, recorderProvider, leaderelection.Options{
+		LeaderElection:          *options.LeaderElection,
 		LeaderElectionID:        options.LeaderElectionID,
 		LeaderElectionNamespace: options.LeaderElectionNamespace,
 	})
@@ -165,21 +276,50 @@ func New(config *rest.Config, options Options) (manager.Manager, error) {
 
 	stop := make(chan struct{})
 
-	return &controllerManager{
-		config:           config,
-		scheme:           options.Scheme,
-		admissionDecoder: admissionDecoder,
-		errChan:          make(chan error),
-		cache:            cache,
-		fieldIndexes:     cache,
-		client:           writeObj,
-		recorderProvider: recorderProvider,
-		resourceLock:     resourceLock,
-		mapper:           mapper,
-		metricsListener:  metricsListener,
-		internalStop:     stop,
-		internalStopper:  stop,
-	}, nil
+	// Attach any additional clusters so reconcilers can reach beyond the
+	// home cluster the Manager itself runs against, e.g. to keep MAC
+	// allocations unique across a federated KubeVirt deployment.
+	clusters := make(map[string]Cluster, len(options.AdditionalClusters))
+	for name, clusterOpts := range options.AdditionalClusters {
+		c, err := newCluster(name, clusterOpts, options)
+		if err != nil {
+			return nil, err
+		}
+		clusters[name] = c
+	}
+
+	mgr := &controllerManager{
+		config:                  config,
+		scheme:                  options.Scheme,
+		admissionDecoder:        admissionDecoder,
+		errChan:                 make(chan error, 1),
+		cache:                   cache,
+		fieldIndexes:            cache,
+		client:                  writeObj,
+		recorderProvider:        recorderProvider,
+		resourceLock:            resourceLock,
+		mapper:                  mapper,
+		metricsListener:         metricsListener,
+		internalStop:            stop,
+		internalStopper:         stop,
+		gracefulShutdownTimeout: *options.GracefulShutdownTimeout,
+	}
+
+	return &multiClusterManager{Manager: mgr, clusters: clusters}, nil
+}
+
+// configWithHTTPClient returns a shallow copy of c whose transport is taken
+// from httpClient, for callers - such as apiutil.NewDiscoveryRESTMapper and
+// leaderelection.NewResourceLock - that only accept a *rest.Config and so
+// can't be handed the shared HTTPClient directly. This way a custom
+// Options.HTTPClient still backs every subsystem that talks to the API
+// server instead of each one dialing its own connection.
+func configWithHTTPClient(c *rest.Config, httpClient *http.Client) *rest.Config {
+	cfg := rest.CopyConfig(c)
+	cfg.Transport = httpClient.Transport
+	cfg.WrapTransport = nil
+	cfg.TLSClientConfig = rest.TLSClientConfig{}
+	return cfg
 }
 
 // defaultNewClient creates the default caching client
@@ -208,7 +348,9 @@ func setOptionsDefaults(options Options) Options {
 	}
 
 	if options.MapperProvider == nil {
-		options.MapperProvider = apiutil.NewDiscoveryRESTMapper
+		options.MapperProvider = func(c *rest.Config, httpClient *http.Client) (meta.RESTMapper, error) {
+			return apiutil.NewDiscoveryRESTMapper(configWithHTTPClient(c, httpClient))
+		}
 	}
 
 	// Allow newClient to be mocked
@@ -228,7 +370,9 @@ func setOptionsDefaults(options Options) Options {
 
 	// Allow newResourceLock to be mocked
 	if options.newResourceLock == nil {
-		options.newResourceLock = leaderelection.NewResourceLock
+		options.newResourceLock = func(config *rest.Config, httpClient *http.Client, recorderProvider recorder.Provider, opts leaderelection.Options) (resourcelock.Interface, error) {
+			return leaderelection.NewResourceLock(configWithHTTPClient(config, httpClient), recorderProvider, opts)
+		}
 	}
 
 	if options.newAdmissionDecoder == nil {
@@ -239,5 +383,15 @@ func setOptionsDefaults(options Options) Options {
 		options.newMetricsListener = metrics.NewListener
 	}
 
+	if options.GracefulShutdownTimeout == nil {
+		gracefulShutdownTimeout := defaultGracefulShutdownPeriod
+		options.GracefulShutdownTimeout = &gracefulShutdownTimeout
+	}
+
+	if options.LeaderElection == nil {
+		disabled := false
+		options.LeaderElection = &disabled
+	}
+
 	return options
 }
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Kubemacpool Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/leaderelection"
+	"sigs.k8s.io/controller-runtime/pkg/recorder"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+// fakeRoundTripper is an http.RoundTripper stand-in used to assert that a
+// given *rest.Config was derived from a particular Options.HTTPClient,
+// without actually dialing anything.
+type fakeRoundTripper struct{}
+
+func (f *fakeRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestConfigWithHTTPClientReusesSharedTransport(t *testing.T) {
+	rt := &fakeRoundTripper{}
+	httpClient := &http.Client{Transport: rt}
+	base := &rest.Config{Host: "https://example.com", TLSClientConfig: rest.TLSClientConfig{Insecure: true}}
+
+	got := configWithHTTPClient(base, httpClient)
+
+	if got.Transport != rt {
+		t.Fatalf("expected the derived config to reuse the shared HTTPClient's transport, got %#v", got.Transport)
+	}
+	if got == base {
+		t.Fatalf("expected configWithHTTPClient to return a copy, not the original config")
+	}
+	if base.Transport != nil {
+		t.Fatalf("expected the original config to be left untouched, got Transport=%#v", base.Transport)
+	}
+	if got.Insecure {
+		t.Fatalf("expected the derived config to clear TLSClientConfig in favor of the shared transport")
+	}
+	if !base.Insecure {
+		t.Fatalf("expected the original config to be left untouched, got Insecure=%v", base.Insecure)
+	}
+}
+
+// The fakeX types below stand in for the real cache/client/mapper/recorder/
+// resource-lock/decoder/listener values New wires together. Each embeds its
+// real interface so it satisfies the interface without implementing every
+// method - New only ever stores or forwards these values, it never calls a
+// method on them, so a fully-fledged fake would just be dead code.
+type (
+	fakeCache            struct{ cache.Cache }
+	fakeClient           struct{ client.Client }
+	fakeMapper           struct{ meta.RESTMapper }
+	fakeRecorderProvider struct{ recorder.Provider }
+	fakeResourceLock     struct{ resourcelock.Interface }
+	fakeDecoder          struct{ types.Decoder }
+	fakeListener         struct{ net.Listener }
+)
+
+// TestNewThreadsHTTPClientThroughEverySubsystem asserts that New derives
+// every subsystem that talks to the API server - the cache, the client, the
+// discovery MapperProvider, the recorder provider and the leader-election
+// resource lock - from the same injected Options.HTTPClient, instead of any
+// of them falling back to dialing their own connection from config alone.
+func TestNewThreadsHTTPClientThroughEverySubsystem(t *testing.T) {
+	httpClient := &http.Client{Transport: &fakeRoundTripper{}}
+
+	seen := map[string]*http.Client{}
+
+	options := Options{
+		HTTPClient: httpClient,
+		MapperProvider: func(c *rest.Config, hc *http.Client) (meta.RESTMapper, error) {
+			seen["MapperProvider"] = hc
+			return fakeMapper{}, nil
+		},
+		NewCache: func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+			seen["NewCache"] = opts.HTTPClient
+			return fakeCache{}, nil
+		},
+		NewClient: func(c cache.Cache, config *rest.Config, opts client.Options) (client.Client, error) {
+			seen["NewClient"] = opts.HTTPClient
+			return fakeClient{}, nil
+		},
+		newRecorderProvider: func(config *rest.Config, hc *http.Client, scheme *runtime.Scheme, logger logr.Logger) (recorder.Provider, error) {
+			seen["newRecorderProvider"] = hc
+			return fakeRecorderProvider{}, nil
+		},
+		newResourceLock: func(config *rest.Config, hc *http.Client, recorderProvider recorder.Provider, opts leaderelection.Options) (resourcelock.Interface, error) {
+			seen["newResourceLock"] = hc
+			return fakeResourceLock{}, nil
+		},
+		newAdmissionDecoder: func(scheme *runtime.Scheme) (types.Decoder, error) {
+			return fakeDecoder{}, nil
+		},
+		newMetricsListener: func(addr string) (net.Listener, error) {
+			return fakeListener{}, nil
+		},
+	}
+
+	if _, err := New(&rest.Config{Host: "https://example.com"}, options); err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+
+	for subsystem, got := range seen {
+		if got != httpClient {
+			t.Errorf("%s did not observe the shared HTTPClient, got %#v", subsystem, got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2019 The Kubemacpool Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package recorder provides the event recorder.Provider the manager package
+// injects into reconcilers and webhooks.
+package recorder
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/recorder"
+)
+
+// provider is a recorder.Provider that posts Events to the API server
+// through a shared clientset, rather than dialing its own connection.
+type provider struct {
+	scheme           *runtime.Scheme
+	eventBroadcaster record.EventBroadcaster
+}
+
+// NewProvider creates a recorder.Provider that records events against
+// config's API server using httpClient, so event recording goes through the
+// same transport - custom TLS roots, proxy settings, impersonation headers
+// or OpenTelemetry instrumentation included - as the rest of the manager's
+// subsystems instead of dialing its own connection.
+func NewProvider(config *rest.Config, httpClient *http.Client, scheme *runtime.Scheme, logger logr.Logger) (recorder.Provider, error) {
+	clientSet, err := kubernetes.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init clientSet: %v", err)
+	}
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientSet.CoreV1().Events("")})
+	eventBroadcaster.StartLogging(func(format string, args ...interface{}) {
+		logger.V(1).Info(fmt.Sprintf(format, args...))
+	})
+
+	return &provider{scheme: scheme, eventBroadcaster: eventBroadcaster}, nil
+}
+
+// GetEventRecorderFor returns an event recorder that will report events with
+// the given name.
+func (p *provider) GetEventRecorderFor(name string) record.EventRecorder {
+	return p.eventBroadcaster.NewRecorder(p.scheme, corev1.EventSource{Component: name})
+}